@@ -0,0 +1,107 @@
+// Package auth performs the login handshake against Vault for the
+// authentication methods yaml-vault supports, returning a client token that
+// callers can hand to client.SetToken.
+package auth
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// Config carries the auth-related flags needed to perform a login. It is
+// populated from the cfg struct in main rather than imported directly so
+// this package stays independent of rconfig.
+type Config struct {
+	Method string // token|approle|kubernetes|jwt|userpass
+	Mount  string // auth mount path, defaults to Method if empty
+
+	Token string // used by the "token" method directly, no login call
+
+	RoleID   string // approle
+	SecretID string // approle
+
+	K8sRole    string // kubernetes
+	K8sJWTPath string // kubernetes, defaults to the projected SA token path
+
+	JWT     string // jwt
+	JWTRole string // jwt
+
+	Username string // userpass
+	Password string // userpass
+}
+
+const defaultK8sJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Login authenticates client according to cfg.Method and returns the
+// resulting token and its lease duration. For the "token" method no network
+// call is made; cfg.Token is returned as-is.
+func Login(client *api.Client, cfg Config) (token string, leaseDuration time.Duration, err error) {
+	switch cfg.Method {
+	case "", "token":
+		if cfg.Token == "" {
+			return "", 0, fmt.Errorf("auth method %q requires a token", "token")
+		}
+		return cfg.Token, 0, nil
+
+	case "approle":
+		return login(client, mountOrDefault(cfg.Mount, "approle"), map[string]interface{}{
+			"role_id":   cfg.RoleID,
+			"secret_id": cfg.SecretID,
+		})
+
+	case "kubernetes":
+		jwtPath := cfg.K8sJWTPath
+		if jwtPath == "" {
+			jwtPath = defaultK8sJWTPath
+		}
+		jwt, err := os.ReadFile(jwtPath)
+		if err != nil {
+			return "", 0, fmt.Errorf("reading kubernetes service account token: %w", err)
+		}
+		return login(client, mountOrDefault(cfg.Mount, "kubernetes"), map[string]interface{}{
+			"role": cfg.K8sRole,
+			"jwt":  string(jwt),
+		})
+
+	case "jwt":
+		return login(client, mountOrDefault(cfg.Mount, "jwt"), map[string]interface{}{
+			"role": cfg.JWTRole,
+			"jwt":  cfg.JWT,
+		})
+
+	case "userpass":
+		path := fmt.Sprintf("auth/%s/login/%s", mountOrDefault(cfg.Mount, "userpass"), cfg.Username)
+		secret, err := client.Logical().Write(path, map[string]interface{}{
+			"password": cfg.Password,
+		})
+		return tokenFromSecret(secret, err)
+
+	default:
+		return "", 0, fmt.Errorf("unsupported auth method %q", cfg.Method)
+	}
+}
+
+func login(client *api.Client, mount string, body map[string]interface{}) (string, time.Duration, error) {
+	secret, err := client.Logical().Write(fmt.Sprintf("auth/%s/login", mount), body)
+	return tokenFromSecret(secret, err)
+}
+
+func tokenFromSecret(secret *api.Secret, err error) (string, time.Duration, error) {
+	if err != nil {
+		return "", 0, err
+	}
+	if secret == nil || secret.Auth == nil {
+		return "", 0, fmt.Errorf("login did not return an auth block")
+	}
+	return secret.Auth.ClientToken, time.Duration(secret.Auth.LeaseDuration) * time.Second, nil
+}
+
+func mountOrDefault(mount, def string) string {
+	if mount == "" {
+		return def
+	}
+	return mount
+}
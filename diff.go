@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+)
+
+const (
+	ansiGreen  = "\033[32m"
+	ansiRed    = "\033[31m"
+	ansiYellow = "\033[33m"
+	ansiReset  = "\033[0m"
+)
+
+// fieldDiff is a structural diff between the value currently stored in
+// Vault and the value about to be written, keyed by field name. Values are
+// compared by a short hash; --diff output lists field names only and shows
+// neither the values nor their hashes, so it is safe to paste into a ticket
+// or CI log.
+type fieldDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func (d fieldDiff) isNoop() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// diffValues compares the current and desired field maps, reporting which
+// field names were added, removed, or changed value (by hash, not content).
+func diffValues(current, desired map[string]interface{}) fieldDiff {
+	var d fieldDiff
+
+	for field, newVal := range desired {
+		oldVal, existed := current[field]
+		if !existed {
+			d.Added = append(d.Added, field)
+			continue
+		}
+		if hashValue(oldVal) != hashValue(newVal) {
+			d.Changed = append(d.Changed, field)
+		}
+	}
+
+	for field := range current {
+		if _, stillPresent := desired[field]; !stillPresent {
+			d.Removed = append(d.Removed, field)
+		}
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+
+	return d
+}
+
+func hashValue(v interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v", v)))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// printDiff writes a colorized unified summary of d for key to stderr.
+func printDiff(key string, d fieldDiff) {
+	fmt.Fprintf(os.Stderr, "%s:\n", key)
+	for _, f := range d.Added {
+		fmt.Fprintf(os.Stderr, "  %s+ %s%s\n", ansiGreen, f, ansiReset)
+	}
+	for _, f := range d.Removed {
+		fmt.Fprintf(os.Stderr, "  %s- %s%s\n", ansiRed, f, ansiReset)
+	}
+	for _, f := range d.Changed {
+		fmt.Fprintf(os.Stderr, "  %s~ %s%s\n", ansiYellow, f, ansiReset)
+	}
+}
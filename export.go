@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// exportFromVault walks every configured export path with a producer/worker
+// pool: one goroutine issues LIST calls to discover leaf paths, while
+// --parallelism workers read them concurrently. A --rate-limit, if set, is
+// shared by all workers and takes one token per leaf key read; LIST calls and
+// the extra KV v2 metadata and version reads for a key are not throttled
+// separately. The resulting keys are sorted by path so repeated exports produce
+// a stable diff.
+func exportFromVault(client *api.Client) error {
+	parallelism := cfg.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+
+	var limiter *rate.Limiter
+	if cfg.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.RateLimit), int(math.Ceil(cfg.RateLimit)))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	paths := make(chan string)
+	results := make(chan importField)
+
+	var firstErr error
+	var errMu sync.Mutex
+	recordErr := func(err error) {
+		errMu.Lock()
+		defer errMu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		if !cfg.IgnoreErrors {
+			cancel()
+		}
+	}
+
+	var producerWG sync.WaitGroup
+	producerWG.Add(1)
+	go func() {
+		defer producerWG.Done()
+		defer close(paths)
+
+		for _, path := range cfg.ExportPaths {
+			if path[0] == '/' {
+				path = path[1:]
+			}
+			if !strings.HasSuffix(path, "/") {
+				path = path + "/"
+			}
+			if err := walkList(ctx, client, path, paths); err != nil {
+				recordErr(err)
+				if !cfg.IgnoreErrors {
+					return
+				}
+				info("Error walking export path %q: %s", path, err)
+			}
+		}
+	}()
+
+	var workersWG sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		workersWG.Add(1)
+		go func() {
+			defer workersWG.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case path, ok := <-paths:
+					if !ok {
+						return
+					}
+					if limiter != nil {
+						if err := limiter.Wait(ctx); err != nil {
+							return
+						}
+					}
+
+					field, err := readField(client, path)
+					if err != nil {
+						if cfg.IgnoreErrors {
+							info("Unable to read %s: %s", path, err)
+							continue
+						}
+						recordErr(err)
+						return
+					}
+					if field == nil {
+						continue
+					}
+
+					select {
+					case results <- *field:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		workersWG.Wait()
+		close(results)
+	}()
+
+	out := importFile{}
+	for field := range results {
+		out.Keys = append(out.Keys, field)
+	}
+
+	producerWG.Wait()
+
+	if firstErr != nil && !cfg.IgnoreErrors {
+		return firstErr
+	}
+
+	sort.Slice(out.Keys, func(i, j int) bool { return out.Keys[i].Key < out.Keys[j].Key })
+
+	data, err := yaml.Marshal(out)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(cfg.File, data, 0600)
+}
+
+// walkList recursively issues LIST calls under path, pushing every leaf
+// secret path it discovers onto out. It returns early (without error) once
+// ctx is cancelled.
+func walkList(ctx context.Context, client *api.Client, path string, out chan<- string) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	default:
+	}
+
+	mount, err := lookupMount(client, path)
+	if err != nil {
+		return err
+	}
+
+	secret, err := client.Logical().List(kvListPath(mount, path))
+	if err != nil {
+		if cfg.IgnoreErrors {
+			info("Error reading %s: %s", path, err)
+			return nil
+		}
+		return fmt.Errorf("Error reading %s: %s", path, err)
+	}
+
+	if secret == nil || secret.Data["keys"] == nil {
+		return nil
+	}
+
+	for _, k := range secret.Data["keys"].([]interface{}) {
+		child := path + k.(string)
+
+		if strings.HasSuffix(child, "/") {
+			if err := walkList(ctx, client, child, out); err != nil {
+				return err
+			}
+			continue
+		}
+
+		select {
+		case out <- child:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// readField reads a single leaf secret, including KV v2 metadata and (with
+// --include-versions) its full version history.
+func readField(client *api.Client, path string) (*importField, error) {
+	mount, err := lookupMount(client, path)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := kvRead(client, mount, path)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		return nil, fmt.Errorf("Unable to read %s", path)
+	}
+
+	field := importField{Key: path, Values: values}
+
+	if mount.Version == "2" {
+		meta, err := client.Logical().Read(kvMetadataPath(mount, path))
+		if err != nil {
+			return nil, err
+		}
+		if meta != nil {
+			field.Metadata = meta.Data
+		}
+
+		if cfg.IncludeVersions {
+			versions, err := kvVersions(client, mount, path)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range versions {
+				vals, err := kvReadVersion(client, mount, path, v)
+				if err != nil {
+					return nil, err
+				}
+				field.Versions = append(field.Versions, vals)
+			}
+		}
+	}
+
+	if xform != nil {
+		field.Key = xform.ApplyPath(field.Key)
+		field.Values = xform.ApplyFields(field.Values)
+		for i, v := range field.Versions {
+			field.Versions[i] = xform.ApplyFields(v)
+		}
+	}
+
+	if cfg.SealBackend != "" || cfg.Redact {
+		sealed, err := sealValues(field.Key, field.Values)
+		if err != nil {
+			return nil, err
+		}
+		field.Values = sealed
+
+		for i, v := range field.Versions {
+			sealedVersion, err := sealValues(field.Key, v)
+			if err != nil {
+				return nil, err
+			}
+			field.Versions[i] = sealedVersion
+		}
+	}
+
+	debug("Successfully read data from key '%s'", path)
+	return &field, nil
+}
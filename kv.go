@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// kvMount describes the secrets engine backing a given path, as reported by
+// sys/mounts. KV v1 and v2 use the same API family but address data and
+// metadata differently, so every read/write/list/delete has to be routed
+// through here first.
+type kvMount struct {
+	Mount   string // mount path, e.g. "secret/"
+	Version string // "1" or "2"
+}
+
+var (
+	mountCacheMu sync.RWMutex
+	mountCache   = map[string]*kvMount{}
+)
+
+// lookupMount resolves the kv mount (and its version) that owns path by
+// consulting sys/mounts. Results are cached per-mount for the lifetime of the
+// process since mount layout does not change mid-run. The cache is read and
+// populated concurrently by the export worker pool, so it is guarded by a
+// mutex rather than relying on a single-goroutine caller.
+func lookupMount(client *api.Client, path string) (*kvMount, error) {
+	trimmed := strings.TrimPrefix(path, "/")
+
+	if m := lookupMountCache(trimmed); m != nil {
+		return m, nil
+	}
+
+	secret, err := client.Logical().Read("sys/mounts")
+	if err != nil {
+		return nil, fmt.Errorf("reading sys/mounts: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("unable to read sys/mounts")
+	}
+
+	mountCacheMu.Lock()
+	defer mountCacheMu.Unlock()
+
+	var best *kvMount
+	for mount, raw := range secret.Data {
+		if !strings.HasPrefix(trimmed, mount) {
+			continue
+		}
+
+		info, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if t, _ := info["type"].(string); t != "kv" && t != "generic" {
+			continue
+		}
+
+		version := "1"
+		if opts, ok := info["options"].(map[string]interface{}); ok {
+			if v, ok := opts["version"].(string); ok && v != "" {
+				version = v
+			}
+		}
+
+		m := &kvMount{Mount: mount, Version: version}
+		mountCache[mount] = m
+
+		if best == nil || len(mount) > len(best.Mount) {
+			best = m
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("unable to determine mount for path %q", path)
+	}
+
+	return best, nil
+}
+
+func lookupMountCache(trimmed string) *kvMount {
+	mountCacheMu.RLock()
+	defer mountCacheMu.RUnlock()
+
+	var best *kvMount
+	for mount, m := range mountCache {
+		if strings.HasPrefix(trimmed, mount) {
+			if best == nil || len(mount) > len(best.Mount) {
+				best = m
+			}
+		}
+	}
+	return best
+}
+
+// kvDataPath returns the path to use for reads/writes of current secret
+// data, rewriting to the "data/" prefix for KV v2 mounts.
+func kvDataPath(m *kvMount, path string) string {
+	if m.Version != "2" {
+		return path
+	}
+	return m.Mount + "data/" + strings.TrimPrefix(path, m.Mount)
+}
+
+// kvMetadataPath returns the path to use for listing and metadata
+// operations, rewriting to the "metadata/" prefix for KV v2 mounts.
+func kvMetadataPath(m *kvMount, path string) string {
+	if m.Version != "2" {
+		return path
+	}
+	return m.Mount + "metadata/" + strings.TrimPrefix(path, m.Mount)
+}
+
+// kvListPath returns the path LIST should be issued against for a directory.
+func kvListPath(m *kvMount, path string) string {
+	if m.Version != "2" {
+		return path
+	}
+	return kvMetadataPath(m, path)
+}
+
+// kvRead fetches the current version of a secret, unwrapping the v2
+// "data"/"metadata" envelope so callers always see the plain field map.
+func kvRead(client *api.Client, m *kvMount, path string) (values map[string]interface{}, err error) {
+	secret, err := client.Logical().Read(kvDataPath(m, path))
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, nil
+	}
+
+	if m.Version != "2" {
+		return secret.Data, nil
+	}
+
+	inner, ok := secret.Data["data"].(map[string]interface{})
+	if !ok || inner == nil {
+		return nil, nil
+	}
+	return inner, nil
+}
+
+// kvWrite writes values to path, wrapping them in the v2 "data"/"options"
+// envelope (including a CAS guard when cas >= 0) or writing them directly
+// for v1 mounts.
+func kvWrite(client *api.Client, m *kvMount, path string, values map[string]interface{}, cas int) error {
+	if m.Version != "2" {
+		_, err := client.Logical().Write(path, values)
+		return err
+	}
+
+	secretData := map[string]interface{}{
+		"data": values,
+	}
+	if cas >= 0 {
+		secretData["options"] = map[string]interface{}{
+			"cas": cas,
+		}
+	}
+
+	_, err := client.Logical().Write(kvDataPath(m, path), secretData)
+	return err
+}
+
+// kvDelete removes a key. For KV v2, destroy selects between soft-deleting
+// the latest version (via the data/ path) and permanently destroying all
+// versions and metadata (via the metadata/ path).
+func kvDelete(client *api.Client, m *kvMount, path string, destroy bool) error {
+	if m.Version != "2" {
+		_, err := client.Logical().Delete(path)
+		return err
+	}
+
+	if destroy {
+		_, err := client.Logical().Delete(kvMetadataPath(m, path))
+		return err
+	}
+
+	_, err := client.Logical().Delete(kvDataPath(m, path))
+	return err
+}
+
+// kvVersions returns the known version numbers for path, oldest first, by
+// reading its metadata. Only meaningful for KV v2 mounts.
+func kvVersions(client *api.Client, m *kvMount, path string) ([]int, error) {
+	if m.Version != "2" {
+		return nil, nil
+	}
+
+	secret, err := client.Logical().Read(kvMetadataPath(m, path))
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+
+	versionsRaw, ok := secret.Data["versions"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	out := make([]int, 0, len(versionsRaw))
+	for k := range versionsRaw {
+		var v int
+		if _, err := fmt.Sscanf(k, "%d", &v); err != nil {
+			continue
+		}
+		out = append(out, v)
+	}
+
+	for i := 1; i < len(out); i++ {
+		for j := i; j > 0 && out[j-1] > out[j]; j-- {
+			out[j-1], out[j] = out[j], out[j-1]
+		}
+	}
+
+	return out, nil
+}
+
+// kvReadVersion reads a specific historical version of a KV v2 secret.
+func kvReadVersion(client *api.Client, m *kvMount, path string, version int) (map[string]interface{}, error) {
+	secret, err := client.Logical().ReadWithData(kvDataPath(m, path), map[string][]string{
+		"version": {fmt.Sprintf("%d", version)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, nil
+	}
+
+	inner, ok := secret.Data["data"].(map[string]interface{})
+	if !ok || inner == nil {
+		return nil, nil
+	}
+	return inner, nil
+}
@@ -1,31 +1,64 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"log"
 	"os"
-	"strings"
-	"text/template"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/Luzifer/rconfig"
 	"github.com/hashicorp/vault/api"
 	"github.com/mitchellh/go-homedir"
+
+	"github.com/deanmax/yaml-vault/auth"
 )
 
 var (
 	cfg = struct {
-		File           string   `flag:"file,f" default:"vault.yaml" description:"File to import from / export to"`
-		Import         bool     `flag:"import" default:"false" description:"Enable importing data into Vault"`
-		Export         bool     `flag:"export" default:"false" description:"Enable exporting data from Vault"`
-		ExportPaths    []string `flag:"export-paths" default:"secret" description:"Which paths to export"`
-		IgnoreErrors   bool     `flag:"ignore-errors" default:"false" description:"Do not exit on read/write errors"`
-		VaultAddress   string   `flag:"vault-addr" env:"VAULT_ADDR" default:"https://127.0.0.1:8200" description:"Vault API address"`
-		VaultToken     string   `flag:"vault-token" env:"VAULT_TOKEN" vardefault:"vault-token" description:"Specify a token to use instead of app-id auth"`
-		VersionAndExit bool     `flag:"version" default:"false" description:"Print program version and exit"`
-		Verbose        bool     `flag:"verbose,v" default:"false" description:"Print verbose output"`
+		File            string   `flag:"file,f" default:"vault.yaml" description:"File to import from / export to"`
+		Import          bool     `flag:"import" default:"false" description:"Enable importing data into Vault"`
+		Export          bool     `flag:"export" default:"false" description:"Enable exporting data from Vault"`
+		ExportPaths     []string `flag:"export-paths" default:"secret" description:"Which paths to export"`
+		IgnoreErrors    bool     `flag:"ignore-errors" default:"false" description:"Do not exit on read/write errors"`
+		VaultAddress    string   `flag:"vault-addr" env:"VAULT_ADDR" default:"https://127.0.0.1:8200" description:"Vault API address"`
+		VaultToken      string   `flag:"vault-token" env:"VAULT_TOKEN" vardefault:"vault-token" description:"Vault token used by the token auth method"`
+		VersionAndExit  bool     `flag:"version" default:"false" description:"Print program version and exit"`
+		Verbose         bool     `flag:"verbose,v" default:"false" description:"Print verbose output"`
+		CAS             int      `flag:"cas" default:"-1" description:"KV v2 check-and-set index to require on writes (-1 disables CAS)"`
+		IncludeVersions bool     `flag:"include-versions" default:"false" description:"Export the full KV v2 version history for each key"`
+
+		VaultAuthMethod string `flag:"vault-auth-method" default:"token" description:"Vault auth method to use: token|approle|kubernetes|jwt|userpass"`
+		VaultAuthMount  string `flag:"vault-auth-mount" default:"" description:"Non-default mount path for the chosen auth method"`
+		VaultRoleID     string `flag:"vault-role-id" default:"" description:"AppRole role_id"`
+		VaultSecretID   string `flag:"vault-secret-id" default:"" description:"AppRole secret_id"`
+		VaultK8sRole    string `flag:"vault-k8s-role" default:"" description:"Kubernetes auth role"`
+		VaultK8sJWTPath string `flag:"vault-k8s-jwt-path" default:"" description:"Path to the service-account JWT (defaults to the projected token path)"`
+		VaultJWT        string `flag:"vault-jwt" default:"" description:"JWT to present to the jwt/oidc auth method"`
+		VaultJWTRole    string `flag:"vault-jwt-role" default:"" description:"Role bound to the jwt auth method"`
+		VaultUsername   string `flag:"vault-username" default:"" description:"Username for the userpass auth method"`
+		VaultPassword   string `flag:"vault-password" default:"" description:"Password for the userpass auth method"`
+
+		Parallelism int     `flag:"parallelism" default:"0" description:"Number of concurrent export workers (0 = GOMAXPROCS)"`
+		RateLimit   float64 `flag:"rate-limit" default:"0" description:"Maximum secret keys read per second during export; LIST calls and extra KV v2 metadata/version reads are not counted (0 = unlimited)"`
+
+		Transform     []string `flag:"transform" default:"" description:"Rename a field: oldkey=newkey (repeatable)"`
+		TransformPath []string `flag:"transform-path" default:"" description:"Rewrite a key path prefix: oldprefix=newprefix (repeatable)"`
+		TransformSet  []string `flag:"transform-set" default:"" description:"Apply a named transform preset, e.g. snake-to-screaming or strip-prefix:secret/ (repeatable)"`
+
+		SealBackend    string   `flag:"seal-backend" default:"" description:"Seal exported fields at rest using this backend: age|kms (op only resolves op:// references on import and cannot seal)"`
+		AgeRecipient   []string `flag:"age-recipient" default:"" description:"age X25519 recipient to seal values for (repeatable)"`
+		AgeIdentity    string   `flag:"age-identity" default:"" description:"Path to an age identity file used to unseal values"`
+		KMSKeyID       string   `flag:"kms-key-id" default:"" description:"AWS KMS key ID or alias (e.g. alias/yaml-vault) used to seal values"`
+		KMSRegion      string   `flag:"kms-region" default:"" description:"AWS region for the KMS client"`
+		OPConnectHost  string   `flag:"op-connect-host" env:"OP_CONNECT_HOST" default:"" description:"1Password Connect server URL, for resolving op:// references"`
+		OPConnectToken string   `flag:"op-connect-token" env:"OP_CONNECT_TOKEN" default:"" description:"1Password Connect API token"`
+		Redact         bool     `flag:"redact" default:"false" description:"Export references instead of ciphertext, safe for git-committed configs"`
+		Reveal         bool     `flag:"reveal" default:"false" description:"Unseal !secret fields on import (required to write their plaintext to Vault)"`
+
+		DryRun      bool `flag:"dry-run" default:"false" description:"Compute and print the diff for each key, but perform no writes or deletes"`
+		Diff        bool `flag:"diff" default:"false" description:"Print a diff for each key before writing it"`
+		OnlyChanged bool `flag:"only-changed" default:"false" description:"Skip writes that would not change the value already in Vault"`
 	}{}
 
 	version = "dev"
@@ -36,9 +69,11 @@ type importFile struct {
 }
 
 type importField struct {
-	Key    string
-	State  string
-	Values map[string]interface{}
+	Key      string
+	State    string
+	Values   map[string]interface{}
+	Metadata map[string]interface{}   `yaml:"metadata,omitempty"`
+	Versions []map[string]interface{} `yaml:"versions,omitempty"`
 }
 
 type execFunction func(*api.Client) error
@@ -78,7 +113,7 @@ func init() {
 		os.Exit(0)
 	}
 
-	if cfg.VaultToken == "" {
+	if cfg.VaultAuthMethod == "token" && cfg.VaultToken == "" {
 		log.Fatalf("[ERR] You need to set vault-token")
 	}
 
@@ -106,7 +141,32 @@ func main() {
 		log.Fatalf("Unable to create client: %s", err)
 	}
 
-	client.SetToken(cfg.VaultToken)
+	token, _, err := auth.Login(client, auth.Config{
+		Method:     cfg.VaultAuthMethod,
+		Mount:      cfg.VaultAuthMount,
+		Token:      cfg.VaultToken,
+		RoleID:     cfg.VaultRoleID,
+		SecretID:   cfg.VaultSecretID,
+		K8sRole:    cfg.VaultK8sRole,
+		K8sJWTPath: cfg.VaultK8sJWTPath,
+		JWT:        cfg.VaultJWT,
+		JWTRole:    cfg.VaultJWTRole,
+		Username:   cfg.VaultUsername,
+		Password:   cfg.VaultPassword,
+	})
+	if err != nil {
+		log.Fatalf("Unable to authenticate to Vault: %s", err)
+	}
+
+	client.SetToken(token)
+
+	if err := buildTransforms(); err != nil {
+		log.Fatalf("[ERR] %s", err)
+	}
+
+	if err := buildSealBackends(); err != nil {
+		log.Fatalf("[ERR] %s", err)
+	}
 
 	var ex execFunction
 
@@ -121,79 +181,15 @@ func main() {
 	}
 }
 
-func exportFromVault(client *api.Client) error {
-	out := importFile{}
-
-	for _, path := range cfg.ExportPaths {
-		if path[0] == '/' {
-			path = path[1:]
-		}
-
-		if !strings.HasSuffix(path, "/") {
-			path = path + "/"
-		}
-
-		if err := readRecurse(client, path, &out); err != nil {
-			return err
-		}
-	}
-
-	data, err := yaml.Marshal(out)
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(cfg.File, data, 0600)
-}
-
-func readRecurse(client *api.Client, path string, out *importFile) error {
-	if !strings.HasSuffix(path, "/") {
-		secret, err := client.Logical().Read(path)
-		if err != nil {
-			return err
-		}
-
-		if secret == nil {
-			if cfg.IgnoreErrors {
-				info("Unable to read %s: %#v", path, secret)
-				return nil
-			}
-			return fmt.Errorf("Unable to read %s: %#v", path, secret)
-		}
-
-		out.Keys = append(out.Keys, importField{Key: path, Values: secret.Data})
-		debug("Successfully read data from key '%s'", path)
-		return nil
-	}
-
-	secret, err := client.Logical().List(path)
-	if err != nil {
-		if cfg.IgnoreErrors {
-			info("Error reading %s: %s", path, err)
-			return nil
-		}
-		return fmt.Errorf("Error reading %s: %s", path, err)
-	}
-
-	if secret != nil && secret.Data["keys"] != nil {
-		for _, k := range secret.Data["keys"].([]interface{}) {
-			if err := readRecurse(client, path+k.(string), out); err != nil {
-				return err
-			}
-		}
-		return nil
-	}
-
-	return nil
-}
-
 func importToVault(client *api.Client) error {
+	sealClient = client
+
 	keysRaw, err := os.ReadFile(cfg.File)
 	if err != nil {
 		return err
 	}
 
-	keysRaw, err = parseImportFile(keysRaw)
+	keysRaw, err = parseImportFile(client, keysRaw)
 	if err != nil {
 		return err
 	}
@@ -204,52 +200,93 @@ func importToVault(client *api.Client) error {
 	}
 
 	for _, field := range keys.Keys {
-		if field.State == "absent" {
-			if _, err := client.Logical().Delete(field.Key); err != nil {
+		if xform != nil {
+			field.Key = xform.ApplyPath(field.Key)
+			field.Values = xform.ApplyFields(field.Values)
+			for i, v := range field.Versions {
+				field.Versions[i] = xform.ApplyFields(v)
+			}
+		}
+
+		mount, err := lookupMount(client, field.Key)
+		if err != nil {
+			if cfg.IgnoreErrors {
+				info("Error while resolving mount for key '%s': %s", field.Key, err)
+				continue
+			}
+			return err
+		}
+
+		if field.State == "absent" || field.State == "destroyed" {
+			if cfg.Diff || cfg.DryRun {
+				fmt.Fprintf(os.Stderr, "%s%s: delete (destroy=%t)%s\n", ansiRed, field.Key, field.State == "destroyed", ansiReset)
+			}
+			if cfg.DryRun {
+				continue
+			}
+
+			if err := kvDelete(client, mount, field.Key, field.State == "destroyed"); err != nil {
 				if cfg.IgnoreErrors {
 					info("Error while deleting key '%s': %s", field.Key, err)
 					continue
 				}
 				return err
 			}
-		} else {
-			secretData := map[string]interface{}{
-				"data": field.Values,
+			continue
+		}
+
+		if len(field.Versions) > 0 {
+			if cfg.Diff || cfg.DryRun {
+				fmt.Fprintf(os.Stderr, "%s%s: replay %d version(s)%s\n", ansiYellow, field.Key, len(field.Versions), ansiReset)
 			}
-			if _, err := client.Logical().Write(field.Key, secretData); err != nil {
-				if cfg.IgnoreErrors {
-					info("Error while writing data to key '%s': %s", field.Key, err)
-					continue
+			if cfg.DryRun {
+				continue
+			}
+
+			for _, values := range field.Versions {
+				if err := kvWrite(client, mount, field.Key, values, -1); err != nil {
+					if cfg.IgnoreErrors {
+						info("Error while writing version to key '%s': %s", field.Key, err)
+						continue
+					}
+					return err
 				}
-				return err
 			}
-			debug("Successfully wrote data to key '%s'", field.Key)
+			debug("Successfully replayed %d version(s) to key '%s'", len(field.Versions), field.Key)
+			continue
 		}
-	}
 
-	return nil
-}
+		if cfg.DryRun || cfg.Diff || cfg.OnlyChanged {
+			current, err := kvRead(client, mount, field.Key)
+			if err != nil {
+				return err
+			}
 
-func parseImportFile(in []byte) (out []byte, err error) {
-	funcMap := template.FuncMap{
-		"env": func(name string, v ...string) string {
-			defaultValue := ""
-			if len(v) > 0 {
-				defaultValue = v[0]
+			d := diffValues(current, field.Values)
+
+			if cfg.Diff || cfg.DryRun {
+				printDiff(field.Key, d)
 			}
-			if value, ok := os.LookupEnv(name); ok {
-				return value
+
+			if cfg.DryRun {
+				continue
 			}
-			return defaultValue
-		},
-	}
 
-	t, err := template.New("input file").Funcs(funcMap).Parse(string(in))
-	if err != nil {
-		return nil, err
+			if cfg.OnlyChanged && d.isNoop() {
+				debug("Skipping no-op write to key '%s'", field.Key)
+				continue
+			}
+		}
+
+		if err := kvWrite(client, mount, field.Key, field.Values, cfg.CAS); err != nil {
+			if cfg.IgnoreErrors {
+				info("Error while writing data to key '%s': %s", field.Key, err)
+				continue
+			}
+			return err
+		}
+		debug("Successfully wrote data to key '%s'", field.Key)
 	}
 
-	buf := bytes.NewBuffer([]byte{})
-	err = t.Execute(buf, nil)
-	return buf.Bytes(), err
+	return nil
 }
@@ -0,0 +1,86 @@
+package seal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+)
+
+// ageBackend seals values to one or more X25519 recipients and unseals them
+// with a matching identity. Refs are base64-encoded age ciphertext.
+type ageBackend struct {
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+func newAgeBackend(recipientStrs []string, identityPath string) (*ageBackend, error) {
+	b := &ageBackend{}
+
+	for _, r := range recipientStrs {
+		recipient, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return nil, fmt.Errorf("parsing age recipient %q: %w", r, err)
+		}
+		b.recipients = append(b.recipients, recipient)
+	}
+
+	if identityPath != "" {
+		f, err := os.Open(identityPath)
+		if err != nil {
+			return nil, fmt.Errorf("opening age identity file %q: %w", identityPath, err)
+		}
+		defer f.Close()
+
+		identities, err := age.ParseIdentities(f)
+		if err != nil {
+			return nil, fmt.Errorf("parsing age identity: %w", err)
+		}
+		b.identities = identities
+	}
+
+	return b, nil
+}
+
+func (b *ageBackend) Scheme() string { return "age" }
+
+func (b *ageBackend) Seal(plaintext []byte) (string, error) {
+	if len(b.recipients) == 0 {
+		return "", fmt.Errorf("age: no --age-recipient configured")
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := age.Encrypt(buf, b.recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (b *ageBackend) Unseal(ref string) ([]byte, error) {
+	if len(b.identities) == 0 {
+		return nil, fmt.Errorf("age: no --age-identity configured")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(ref)
+	if err != nil {
+		return nil, fmt.Errorf("age: decoding ref: %w", err)
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(raw), b.identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,58 @@
+package seal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/1Password/connect-sdk-go/connect"
+)
+
+// onePasswordBackend resolves op://vault/item/field references via a
+// 1Password Connect server. It only ever reads existing items -
+// yaml-vault has no business creating 1Password items on export, so Seal
+// is unsupported and callers are expected to author op:// references by
+// hand (see --redact).
+type onePasswordBackend struct {
+	client connect.Client
+}
+
+func newOnePasswordBackend(host, token string) (*onePasswordBackend, error) {
+	return &onePasswordBackend{client: connect.NewClient(host, token)}, nil
+}
+
+func (b *onePasswordBackend) Scheme() string { return "op" }
+
+func (b *onePasswordBackend) Seal([]byte) (string, error) {
+	return "", fmt.Errorf("op: sealing new values is not supported, write an op://vault/item/field reference directly")
+}
+
+func (b *onePasswordBackend) Unseal(ref string) ([]byte, error) {
+	vault, item, field, err := parseOPReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := b.client.GetItem(item, vault)
+	if err != nil {
+		return nil, fmt.Errorf("op: fetching %s: %w", ref, err)
+	}
+
+	for _, f := range it.Fields {
+		if f.Label == field {
+			return []byte(f.Value), nil
+		}
+	}
+
+	return nil, fmt.Errorf("op: field %q not found in %s/%s", field, vault, item)
+}
+
+// parseOPReference parses "op://vault/item/field" (the "op://" prefix may
+// already have been stripped by the caller's scheme split).
+func parseOPReference(ref string) (vault, item, field string, err error) {
+	ref = strings.TrimPrefix(ref, "//")
+	parts := strings.SplitN(ref, "/", 3)
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("op: invalid reference %q, want vault/item/field", ref)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
@@ -0,0 +1,66 @@
+// Package seal encrypts secret field values for at-rest storage in the
+// exported YAML and decrypts them again on import, so a committed
+// vault.yaml never needs to carry cleartext. Each Backend owns one ref
+// scheme (e.g. "age:", "kms:", "op://") and callers dispatch on that prefix.
+package seal
+
+import "fmt"
+
+// Backend seals and unseals values for a single ref scheme.
+type Backend interface {
+	// Scheme is the ref prefix this backend owns, e.g. "age", "kms", "op".
+	Scheme() string
+
+	// Seal encrypts plaintext and returns an opaque reference (without the
+	// scheme prefix) safe to commit to version control.
+	Seal(plaintext []byte) (ref string, err error)
+
+	// Unseal resolves a ref produced by Seal (or, for reference-only
+	// backends, a user-authored one) back to its plaintext.
+	Unseal(ref string) (plaintext []byte, err error)
+}
+
+// Config carries the flags needed to construct whichever backends the
+// operator asked for. Backends are only built when their flags are set, so
+// using yaml-vault without any sealing flags stays a no-op.
+type Config struct {
+	AgeRecipients []string
+	AgeIdentity   string
+
+	KMSKeyID     string
+	KMSAWSRegion string
+
+	OPConnectHost  string
+	OPConnectToken string
+}
+
+// Configure builds the set of backends implied by cfg, keyed by scheme.
+func Configure(cfg Config) (map[string]Backend, error) {
+	backends := map[string]Backend{}
+
+	if len(cfg.AgeRecipients) > 0 || cfg.AgeIdentity != "" {
+		b, err := newAgeBackend(cfg.AgeRecipients, cfg.AgeIdentity)
+		if err != nil {
+			return nil, fmt.Errorf("configuring age sealer: %w", err)
+		}
+		backends[b.Scheme()] = b
+	}
+
+	if cfg.KMSKeyID != "" {
+		b, err := newKMSBackend(cfg.KMSKeyID, cfg.KMSAWSRegion)
+		if err != nil {
+			return nil, fmt.Errorf("configuring kms sealer: %w", err)
+		}
+		backends[b.Scheme()] = b
+	}
+
+	if cfg.OPConnectHost != "" {
+		b, err := newOnePasswordBackend(cfg.OPConnectHost, cfg.OPConnectToken)
+		if err != nil {
+			return nil, fmt.Errorf("configuring 1password sealer: %w", err)
+		}
+		backends[b.Scheme()] = b
+	}
+
+	return backends, nil
+}
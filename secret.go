@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hashicorp/vault/api"
+
+	"github.com/deanmax/yaml-vault/seal"
+)
+
+// secretTag marks a field whose value is sealed at rest, e.g.
+// `password: !secret "<ref>"`.
+const secretTag = "!secret"
+
+// sealBackends holds the configured seal.Backend instances, keyed by
+// scheme ("age", "kms", "op"). Populated once in main from the --age-*,
+// --kms-* and --op-* flags; left empty (a no-op) when none are set.
+var sealBackends map[string]seal.Backend
+
+// sealClient is the authenticated client active during importToVault,
+// needed to resolve --redact references back to their live Vault value.
+var sealClient *api.Client
+
+func buildSealBackends() error {
+	backends, err := seal.Configure(seal.Config{
+		AgeRecipients:  cfg.AgeRecipient,
+		AgeIdentity:    cfg.AgeIdentity,
+		KMSKeyID:       cfg.KMSKeyID,
+		KMSAWSRegion:   cfg.KMSRegion,
+		OPConnectHost:  cfg.OPConnectHost,
+		OPConnectToken: cfg.OPConnectToken,
+	})
+	if err != nil {
+		return err
+	}
+	sealBackends = backends
+	return nil
+}
+
+// UnmarshalYAML decodes an importField while resolving any !secret-tagged
+// values through unsealValues, so the rest of the codebase only ever sees
+// plain field maps.
+func (f *importField) UnmarshalYAML(node *yaml.Node) error {
+	var raw struct {
+		Key      string
+		State    string
+		Values   map[string]yaml.Node
+		Metadata map[string]interface{} `yaml:"metadata,omitempty"`
+		Versions []map[string]yaml.Node `yaml:"versions,omitempty"`
+	}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+
+	values, err := unsealValues(raw.Values)
+	if err != nil {
+		return err
+	}
+
+	f.Key = raw.Key
+	f.State = raw.State
+	f.Values = values
+	f.Metadata = raw.Metadata
+
+	for _, v := range raw.Versions {
+		vv, err := unsealValues(v)
+		if err != nil {
+			return err
+		}
+		f.Versions = append(f.Versions, vv)
+	}
+
+	return nil
+}
+
+// sealedValue marshals as a !secret-tagged scalar holding an opaque ref.
+type sealedValue struct {
+	ref string
+}
+
+func (s *sealedValue) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: secretTag, Value: s.ref}, nil
+}
+
+// sealValues seals every field in values for storage under key, producing
+// sealedValue entries that marshal with the !secret tag.
+//
+// With --redact the ref is not ciphertext at all but a pointer back to
+// "<key>#<field>": the committed YAML carries no secret material, and a
+// --reveal import resolves it by reading the live value straight out of
+// Vault again.
+func sealValues(key string, values map[string]interface{}) (map[string]interface{}, error) {
+	if cfg.SealBackend == "" && !cfg.Redact {
+		return values, nil
+	}
+
+	var backend seal.Backend
+	if !cfg.Redact {
+		var ok bool
+		backend, ok = sealBackends[cfg.SealBackend]
+		if !ok {
+			return nil, fmt.Errorf("no sealer configured for --seal-backend %q", cfg.SealBackend)
+		}
+	}
+
+	out := make(map[string]interface{}, len(values))
+	for field, v := range values {
+		if cfg.Redact {
+			out[field] = &sealedValue{ref: fmt.Sprintf("%s#%s", key, field)}
+			continue
+		}
+
+		ref, err := backend.Seal([]byte(fmt.Sprintf("%v", v)))
+		if err != nil {
+			return nil, fmt.Errorf("sealing field %q of %q: %w", field, key, err)
+		}
+		out[field] = &sealedValue{ref: backend.Scheme() + ":" + ref}
+	}
+
+	return out, nil
+}
+
+// unsealValues walks a decoded values map, replacing any !secret-tagged
+// field with its decrypted plaintext (or, for a --redact reference, the
+// value currently stored at the referenced Vault key/field). Requires
+// --reveal, so a sealed field never ends up written to Vault by accident.
+func unsealValues(nodes map[string]yaml.Node) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(nodes))
+
+	for k, node := range nodes {
+		node := node
+		if node.Tag != secretTag {
+			var v interface{}
+			if err := node.Decode(&v); err != nil {
+				return nil, err
+			}
+			out[k] = v
+			continue
+		}
+
+		if !cfg.Reveal {
+			return nil, fmt.Errorf("field %q is sealed; pass --reveal to unseal it on import", k)
+		}
+
+		plaintext, err := unsealRef(node.Value)
+		if err != nil {
+			return nil, fmt.Errorf("unsealing field %q: %w", k, err)
+		}
+
+		out[k] = string(plaintext)
+	}
+
+	return out, nil
+}
+
+func unsealRef(ref string) ([]byte, error) {
+	scheme, rest := splitSchemeRef(ref)
+
+	if scheme == "" {
+		return resolveRedactedRef(rest)
+	}
+
+	backend, ok := sealBackends[scheme]
+	if !ok {
+		return nil, fmt.Errorf("no sealer configured for scheme %q", scheme)
+	}
+	return backend.Unseal(rest)
+}
+
+// resolveRedactedRef resolves a "<key>#<field>" reference written by
+// --redact by reading the field's live value out of Vault.
+func resolveRedactedRef(ref string) ([]byte, error) {
+	if sealClient == nil {
+		return nil, fmt.Errorf("no Vault client available to resolve redacted reference %q", ref)
+	}
+
+	key, field, ok := strings.Cut(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("invalid redacted reference %q, want key#field", ref)
+	}
+
+	mount, err := lookupMount(sealClient, key)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := kvRead(sealClient, mount, key)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := values[field]
+	if !ok {
+		return nil, fmt.Errorf("field %q not found at %q", field, key)
+	}
+
+	return []byte(fmt.Sprintf("%v", v)), nil
+}
+
+func splitSchemeRef(ref string) (scheme, rest string) {
+	for i := 0; i < len(ref); i++ {
+		if ref[i] == ':' {
+			return ref[:i], ref[i+1:]
+		}
+	}
+	return "", ref
+}
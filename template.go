@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"golang.org/x/term"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// includeRe matches `{{- /* import "other.yaml" */ -}}` style directives,
+// resolved by resolveIncludes before the result is handed to text/template.
+var includeRe = regexp.MustCompile(`\{\{-?\s*/\*\s*import\s+"([^"]+)"\s*\*/\s*-?\}\}`)
+
+// parseImportFile renders cfg.File as a text/template before it is parsed
+// as YAML. Besides the sprig function set and the original env helper, the
+// FuncMap exposes vault (cross-referencing another Vault key at render
+// time, using the same authenticated client) and file/fileB64 for inlining
+// file contents such as TLS bundles. client is required for vault lookups,
+// so this can no longer run at init-time in isolation.
+func parseImportFile(client *api.Client, in []byte) (out []byte, err error) {
+	in, err = resolveIncludes(filepath.Dir(cfg.File), in, map[string]bool{mustAbs(cfg.File): true})
+	if err != nil {
+		return nil, err
+	}
+
+	funcMap := sprig.TxtFuncMap()
+
+	funcMap["env"] = func(name string, v ...string) string {
+		defaultValue := ""
+		if len(v) > 0 {
+			defaultValue = v[0]
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return defaultValue
+	}
+
+	funcMap["vault"] = func(path, field string) (string, error) {
+		return vaultLookup(client, path, field)
+	}
+
+	funcMap["file"] = func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		return string(data), err
+	}
+
+	funcMap["fileB64"] = func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+
+	funcMap["readPasswordStdin"] = readPasswordStdin
+
+	t, err := template.New("input file").Funcs(funcMap).Parse(string(in))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	err = t.Execute(buf, nil)
+	return buf.Bytes(), err
+}
+
+// vaultLookup reads field from path using the same mount-detection and KV
+// v1/v2 handling as the rest of the importer.
+func vaultLookup(client *api.Client, path, field string) (string, error) {
+	mount, err := lookupMount(client, path)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := kvRead(client, mount, path)
+	if err != nil {
+		return "", err
+	}
+	if values == nil {
+		return "", fmt.Errorf("vault: no value found at %q", path)
+	}
+
+	v, ok := values[field]
+	if !ok {
+		return "", fmt.Errorf("vault: field %q not found at %q", field, path)
+	}
+
+	return fmt.Sprintf("%v", v), nil
+}
+
+// readPasswordStdin prints prompt to stderr and reads a line from stdin
+// with echo disabled, for secrets that shouldn't be typed into history or
+// shown on screen.
+func readPasswordStdin(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// resolveIncludes replaces every `import "other.yaml"` directive in in with
+// the contents of that file (resolved relative to dir), recursively, so
+// large secret sets can be split across files before templating begins.
+func resolveIncludes(dir string, in []byte, seen map[string]bool) ([]byte, error) {
+	matches := includeRe.FindAllSubmatchIndex(in, -1)
+	if matches == nil {
+		return in, nil
+	}
+
+	var out []byte
+	last := 0
+
+	for _, m := range matches {
+		out = append(out, in[last:m[0]]...)
+		last = m[1]
+
+		ref := string(in[m[2]:m[3]])
+		path := ref
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		path = mustAbs(path)
+
+		if seen[path] {
+			return nil, fmt.Errorf("circular import of %q", ref)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("import %q: %w", ref, err)
+		}
+
+		childSeen := make(map[string]bool, len(seen)+1)
+		for k := range seen {
+			childSeen[k] = true
+		}
+		childSeen[path] = true
+
+		resolved, err := resolveIncludes(filepath.Dir(path), content, childSeen)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resolved...)
+	}
+
+	out = append(out, in[last:]...)
+	return out, nil
+}
+
+func mustAbs(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/deanmax/yaml-vault/transforms"
+)
+
+// xform is the key/path transformer assembled from --transform,
+// --transform-path and --transform-set. It is built once in main and left
+// nil (a harmless no-op) when none of those flags were given.
+var xform *transforms.Set
+
+func buildTransforms() error {
+	var fields []transforms.FieldRule
+	for _, raw := range cfg.Transform {
+		if raw == "" {
+			continue
+		}
+		rule, err := transforms.ParseFieldRule(raw)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, rule)
+	}
+
+	var paths []transforms.PathRule
+	for _, raw := range cfg.TransformPath {
+		if raw == "" {
+			continue
+		}
+		rule, err := transforms.ParsePathRule(raw)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rule)
+	}
+
+	var presets []string
+	for _, name := range cfg.TransformSet {
+		if name != "" {
+			presets = append(presets, name)
+		}
+	}
+
+	if len(fields) == 0 && len(paths) == 0 && len(presets) == 0 {
+		return nil
+	}
+
+	set, err := transforms.NewSet(fields, paths, presets)
+	if err != nil {
+		return err
+	}
+	xform = set
+	return nil
+}
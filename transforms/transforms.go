@@ -0,0 +1,148 @@
+// Package transforms rewrites field names and key paths as data moves
+// through yaml-vault, so secrets can be migrated between mount layouts
+// (e.g. v1 -> v2, or secret/app/foo -> kv/apps/foo/) without hand-editing
+// the YAML.
+package transforms
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyFunc rewrites a single field name or path.
+type KeyFunc func(string) string
+
+// preset is a named, ready-made transform. Field and Path are nil when the
+// preset does not touch that dimension.
+type preset struct {
+	Field KeyFunc
+	Path  KeyFunc
+}
+
+// registry holds the built-in --transform-set presets, keyed by name. Presets
+// taking a parameter are registered under their colon-prefix and resolved in
+// lookup.
+var registry = map[string]func(arg string) preset{
+	"snake-to-screaming": func(string) preset {
+		return preset{Field: snakeToScreaming}
+	},
+	"strip-prefix": func(arg string) preset {
+		return preset{Path: func(p string) string { return strings.TrimPrefix(p, arg) }}
+	},
+}
+
+func lookup(name string) (preset, error) {
+	key, arg := name, ""
+	if idx := strings.IndexRune(name, ':'); idx >= 0 {
+		key, arg = name[:idx], name[idx+1:]
+	}
+
+	factory, ok := registry[key]
+	if !ok {
+		return preset{}, fmt.Errorf("unknown transform-set %q", name)
+	}
+	return factory(arg), nil
+}
+
+func snakeToScreaming(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(s, "-", "_"))
+}
+
+// FieldRule renames a single field key during ApplyFields.
+type FieldRule struct {
+	Old string
+	New string
+}
+
+// PathRule rewrites paths that start with Prefix to start with Replacement
+// instead.
+type PathRule struct {
+	Prefix      string
+	Replacement string
+}
+
+// ParseFieldRule parses a "oldkey=newkey" flag value.
+func ParseFieldRule(s string) (FieldRule, error) {
+	oldKey, newKey, ok := strings.Cut(s, "=")
+	if !ok {
+		return FieldRule{}, fmt.Errorf("invalid --transform %q, want oldkey=newkey", s)
+	}
+	return FieldRule{Old: oldKey, New: newKey}, nil
+}
+
+// ParsePathRule parses a "oldprefix=newprefix" flag value.
+func ParsePathRule(s string) (PathRule, error) {
+	oldPrefix, newPrefix, ok := strings.Cut(s, "=")
+	if !ok {
+		return PathRule{}, fmt.Errorf("invalid --transform-path %q, want oldprefix=newprefix", s)
+	}
+	return PathRule{Prefix: oldPrefix, Replacement: newPrefix}, nil
+}
+
+// Set is a fully assembled collection of field renames, path rewrites and
+// named presets, built once from CLI flags and then applied to every key.
+type Set struct {
+	fields  []FieldRule
+	paths   []PathRule
+	presets []preset
+}
+
+// NewSet builds a Set from the parsed --transform, --transform-path and
+// --transform-set flag values.
+func NewSet(fields []FieldRule, paths []PathRule, presetNames []string) (*Set, error) {
+	s := &Set{fields: fields, paths: paths}
+
+	for _, name := range presetNames {
+		p, err := lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		s.presets = append(s.presets, p)
+	}
+
+	return s, nil
+}
+
+// ApplyPath rewrites a key path through every configured path rule and
+// preset, in the order they were registered.
+func (s *Set) ApplyPath(path string) string {
+	for _, p := range s.paths {
+		if strings.HasPrefix(path, p.Prefix) {
+			path = p.Replacement + strings.TrimPrefix(path, p.Prefix)
+		}
+	}
+	for _, p := range s.presets {
+		if p.Path != nil {
+			path = p.Path(path)
+		}
+	}
+	return path
+}
+
+// ApplyFields returns a copy of values with every field renamed according to
+// the configured field rules and presets.
+func (s *Set) ApplyFields(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	renames := make(map[string]string, len(s.fields))
+	for _, f := range s.fields {
+		renames[f.Old] = f.New
+	}
+
+	out := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		newKey := k
+		if renamed, ok := renames[k]; ok {
+			newKey = renamed
+		}
+		for _, p := range s.presets {
+			if p.Field != nil {
+				newKey = p.Field(newKey)
+			}
+		}
+		out[newKey] = v
+	}
+	return out
+}